@@ -30,9 +30,17 @@ type BuiltinEvalContext struct {
 	ChildModuleCallArgs  map[string]map[string]cty.Value
 	ChildModuleCallsLock *sync.Mutex
 
-	Components          contextComponentFactory
-	Hooks               []Hook
-	InputValue          UIInput
+	Components contextComponentFactory
+	Hooks      []Hook
+	InputValue UIInput
+	// StdinInputValue, if non-nil, is the single StdinInputProvider shared
+	// by every EvalInputProvider node evaluated against this context, so
+	// that its one JSON object on stdin is decoded exactly once per run
+	// rather than once per provider configuration. It's only set by a
+	// caller that has explicitly opted a run into stdin-sourced provider
+	// input (see StdinInputContext); it's never populated just because
+	// InputValue is nil.
+	StdinInputValue     *StdinInputProvider
 	ProviderCache       map[string]ResourceProvider
 	ProviderSchemas     map[string]*ProviderSchema
 	ProviderInputConfig map[string]map[string]cty.Value
@@ -44,9 +52,44 @@ type BuiltinEvalContext struct {
 	StateValue          *State
 	StateLock           *sync.RWMutex
 
+	// SchemaCacheDir, if non-empty, is a directory where provider schemas
+	// fetched during InitProvider are persisted between runs, so that a
+	// later run can skip the schema RPC round-trip entirely on a cache
+	// hit. See schemaCachePath.
+	//
+	// This package only reads and writes whatever SchemaCacheDir is set
+	// to; it's left empty (disk caching off, falling back to the
+	// in-memory ProviderSchemas/AllSchemas fast path only) unless
+	// whatever constructs a BuiltinEvalContext -- the CLI command layer,
+	// which knows the working directory's ".terraform" data dir and
+	// isn't part of this package -- opts in by setting it, e.g. to
+	// ".terraform/providers/schemas". No such wiring has landed yet, so
+	// as of this commit the on-disk cache exists but is dormant in a real
+	// terraform invocation.
+	SchemaCacheDir string
+
+	// providerInit tracks providers whose initialization (construction and
+	// schema fetch) is currently in progress, keyed the same way as
+	// ProviderCache. It lets concurrent InitProvider/Provider/ProviderSchema
+	// calls for the same provider instance wait for the in-flight work to
+	// finish instead of either racing to start it twice or observing a
+	// not-yet-populated cache entry. Guarded by ProviderLock.
+	providerInit map[string]*providerInitState
+
 	once sync.Once
 }
 
+// providerInitState records the result of an in-progress call to
+// InitProvider, so that other goroutines asking for the same provider can
+// block on "done" and then read the result once it's ready, rather than
+// holding ProviderLock for the whole initialization.
+type providerInitState struct {
+	done     chan struct{}
+	provider ResourceProvider
+	schema   *ProviderSchema
+	err      error
+}
+
 // BuiltinEvalContext implements EvalContext
 var _ EvalContext = (*BuiltinEvalContext)(nil)
 
@@ -83,77 +126,129 @@ func (ctx *BuiltinEvalContext) Input() UIInput {
 	return ctx.InputValue
 }
 
+// StdinInput implements StdinInputContext.
+func (ctx *BuiltinEvalContext) StdinInput() InputProvider {
+	if ctx.StdinInputValue == nil {
+		return nil
+	}
+	return ctx.StdinInputValue
+}
+
 func (ctx *BuiltinEvalContext) InitProvider(typeName string, addr addrs.ProviderConfig) (ResourceProvider, error) {
 	ctx.once.Do(ctx.init)
 
-	// If we already initialized, it is an error
-	if p := ctx.Provider(addr); p != nil {
+	key := addr.String()
+
+	ctx.ProviderLock.Lock()
+	if _, exists := ctx.ProviderCache[key]; exists {
+		ctx.ProviderLock.Unlock()
+		return nil, fmt.Errorf("%s is already initialized", addr)
+	}
+	if _, exists := ctx.providerInit[key]; exists {
+		ctx.ProviderLock.Unlock()
 		return nil, fmt.Errorf("%s is already initialized", addr)
 	}
 
-	// Warning: make sure to acquire these locks AFTER the call to Provider
-	// above, since it also acquires locks.
+	state := &providerInitState{done: make(chan struct{})}
+	if ctx.providerInit == nil {
+		ctx.providerInit = make(map[string]*providerInitState)
+	}
+	ctx.providerInit[key] = state
+	ctx.ProviderLock.Unlock()
+
+	// The construction of the provider and the fetch of its schema can be
+	// slow -- for plugin-based providers this is RPC to a separate process
+	// -- so we deliberately do this work without holding ProviderLock. This
+	// allows the graph walker to initialize unrelated providers
+	// concurrently. Other callers asking for this same provider in the
+	// meantime will block on state.done below, rather than racing to
+	// initialize it themselves or observing a not-yet-populated cache.
+	p, schema, err := ctx.startProvider(typeName, key)
+
+	state.provider, state.schema, state.err = p, schema, err
+	close(state.done)
+
 	ctx.ProviderLock.Lock()
-	defer ctx.ProviderLock.Unlock()
+	delete(ctx.providerInit, key)
+	if err == nil {
+		ctx.ProviderCache[key] = p
+		if ctx.ProviderSchemas == nil {
+			ctx.ProviderSchemas = make(map[string]*ProviderSchema)
+		}
+		ctx.ProviderSchemas[key] = schema
+	}
+	ctx.ProviderLock.Unlock()
 
-	key := addr.String()
+	return p, err
+}
 
+// startProvider constructs the provider for the given key and retrieves its
+// schema. It must be called without ProviderLock held, since it may do
+// substantial RPC work against a plugin-based provider.
+func (ctx *BuiltinEvalContext) startProvider(typeName, key string) (ResourceProvider, *ProviderSchema, error) {
 	p, err := ctx.Components.ResourceProvider(typeName, key)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	ctx.ProviderCache[key] = p
-
-	// Also fetch and cache the provider's schema.
-	// FIXME: This is using a non-ideal provider API that requires us to
-	// request specific resource types, but we actually just want _all_ the
-	// resource types, so we'll list these first. Once the provider API is
-	// updated we'll get enough data to populate this whole structure in
-	// a single call.
-	resourceTypes := p.Resources()
-	dataSources := p.DataSources()
-	resourceTypeNames := make([]string, len(resourceTypes))
-	for i, t := range resourceTypes {
-		resourceTypeNames[i] = t.Name
-	}
-	dataSourceNames := make([]string, len(dataSources))
-	for i, t := range dataSources {
-		dataSourceNames[i] = t.Name
+	cacheKey := schemaCacheKey(typeName)
+	if schema := ctx.readSchemaCache(cacheKey); schema != nil {
+		return p, schema, nil
 	}
-	schema, err := p.GetSchema(&ProviderSchemaRequest{
-		DataSources:   dataSourceNames,
-		ResourceTypes: resourceTypeNames,
-	})
+
+	schema, err := getFullProviderSchema(p)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching schema for %s: %s", key, err)
+		return nil, nil, fmt.Errorf("error fetching schema for %s: %s", key, err)
 	}
-	if ctx.ProviderSchemas == nil {
-		ctx.ProviderSchemas = make(map[string]*ProviderSchema)
-	}
-	ctx.ProviderSchemas[key] = schema
 
-	return p, nil
+	ctx.writeSchemaCache(cacheKey, schema)
+
+	return p, schema, nil
 }
 
 func (ctx *BuiltinEvalContext) Provider(addr addrs.ProviderConfig) ResourceProvider {
 	ctx.once.Do(ctx.init)
 
+	state := ctx.awaitProviderInit(addr)
+	if state != nil {
+		return state.provider
+	}
+
 	ctx.ProviderLock.Lock()
 	defer ctx.ProviderLock.Unlock()
-
 	return ctx.ProviderCache[addr.String()]
 }
 
 func (ctx *BuiltinEvalContext) ProviderSchema(addr addrs.ProviderConfig) *ProviderSchema {
 	ctx.once.Do(ctx.init)
 
+	state := ctx.awaitProviderInit(addr)
+	if state != nil {
+		return state.schema
+	}
+
 	ctx.ProviderLock.Lock()
 	defer ctx.ProviderLock.Unlock()
-
 	return ctx.ProviderSchemas[addr.String()]
 }
 
+// awaitProviderInit returns the providerInitState for addr if that provider
+// is currently being initialized by a concurrent call to InitProvider,
+// blocking until that initialization completes. It returns nil if there is
+// no initialization in progress for addr, in which case the caller should
+// consult ProviderCache/ProviderSchemas directly.
+func (ctx *BuiltinEvalContext) awaitProviderInit(addr addrs.ProviderConfig) *providerInitState {
+	ctx.ProviderLock.Lock()
+	state, pending := ctx.providerInit[addr.String()]
+	ctx.ProviderLock.Unlock()
+	if !pending {
+		return nil
+	}
+
+	<-state.done
+	return state
+}
+
 func (ctx *BuiltinEvalContext) CloseProvider(addr addrs.ProviderConfig) error {
 	ctx.once.Do(ctx.init)
 
@@ -166,6 +261,7 @@ func (ctx *BuiltinEvalContext) CloseProvider(addr addrs.ProviderConfig) error {
 	if provider != nil {
 		if p, ok := provider.(ResourceProviderCloser); ok {
 			delete(ctx.ProviderCache, key)
+			delete(ctx.ProviderSchemas, key)
 			return p.Close()
 		}
 	}
@@ -0,0 +1,119 @@
+package terraform
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+)
+
+// TestBuiltinEvalContext_InitProviderConcurrent exercises InitProvider,
+// Provider, and ProviderSchema called concurrently for both distinct and
+// identical provider configuration addresses. It's meant to be run with
+// -race: the providerInit bookkeeping that lets concurrent InitProvider
+// calls for different providers proceed in parallel, while a caller asking
+// about a provider that's still initializing blocks instead of racing the
+// ProviderCache/ProviderSchemas maps, is exactly the kind of change a
+// normal (non-race) test run won't catch a regression in.
+func TestBuiltinEvalContext_InitProviderConcurrent(t *testing.T) {
+	newCtx := func() *BuiltinEvalContext {
+		return &BuiltinEvalContext{
+			Components: &basicComponentFactory{
+				ResourceProviders: map[string]ResourceProviderFactory{
+					"test": func() (ResourceProvider, error) {
+						return &MockResourceProvider{
+							GetSchemaReturn: &ProviderSchema{},
+						}, nil
+					},
+				},
+			},
+			ProviderCache: make(map[string]ResourceProvider),
+			ProviderLock:  new(sync.Mutex),
+		}
+	}
+
+	addrFor := func(alias string) addrs.ProviderConfig {
+		return addrs.ProviderConfig{Type: "test", Alias: alias}
+	}
+
+	t.Run("distinct keys initialize concurrently", func(t *testing.T) {
+		ctx := newCtx()
+		aliases := []string{"a", "b", "c", "d"}
+
+		var wg sync.WaitGroup
+		for _, alias := range aliases {
+			alias := alias
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := ctx.InitProvider("test", addrFor(alias)); err != nil {
+					t.Errorf("InitProvider(%q) failed: %s", alias, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for _, alias := range aliases {
+			if p := ctx.Provider(addrFor(alias)); p == nil {
+				t.Errorf("provider %q not initialized", alias)
+			}
+			if s := ctx.ProviderSchema(addrFor(alias)); s == nil {
+				t.Errorf("schema %q not cached", alias)
+			}
+		}
+	})
+
+	t.Run("identical key is initialized exactly once", func(t *testing.T) {
+		ctx := newCtx()
+		addr := addrFor("same")
+
+		const readers = 8
+		var wg sync.WaitGroup
+		var successes int32
+		var mu sync.Mutex
+
+		// Two goroutines race InitProvider against each other for the same
+		// address -- only one attempt may win -- while the others call
+		// Provider/ProviderSchema concurrently, which must either see
+		// nothing yet or the fully-initialized result, and must never
+		// trip the race detector on the underlying caches.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ctx.InitProvider("test", addr); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ctx.InitProvider("test", addr); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}()
+
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx.Provider(addr)
+				ctx.ProviderSchema(addr)
+			}()
+		}
+		wg.Wait()
+
+		if successes != 1 {
+			t.Errorf("got %d successful InitProvider calls for the same address, want exactly 1", successes)
+		}
+		if p := ctx.Provider(addr); p == nil {
+			t.Error("provider not initialized after InitProvider returned")
+		}
+		if s := ctx.ProviderSchema(addr); s == nil {
+			t.Error("schema not cached after InitProvider returned")
+		}
+	})
+}
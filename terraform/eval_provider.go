@@ -8,8 +8,21 @@ import (
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/configs"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
+// providerSchema returns the schema for the provider at addr, preferring
+// the copy already cached on ctx (populated by InitProvider, and shared by
+// every Eval node that needs it) over asking the provider again.
+func providerSchema(ctx EvalContext, addr addrs.ProviderConfig, provider ResourceProvider) (*ProviderSchema, error) {
+	if schema := ctx.ProviderSchema(addr); schema != nil {
+		return schema, nil
+	}
+
+	return provider.GetSchema(&ProviderSchemaRequest{})
+}
+
 func buildProviderConfig(ctx EvalContext, addr addrs.ProviderConfig, body hcl.Body) hcl.Body {
 	// If we have an Input configuration set, then merge that in
 	if input := ctx.ProviderInput(addr); input != nil {
@@ -39,7 +52,7 @@ func (n *EvalConfigProvider) Eval(ctx EvalContext) (interface{}, error) {
 	provider := *n.Provider
 	config := *n.Config
 
-	schema, err := provider.GetSchema(&ProviderSchemaRequest{})
+	schema, err := providerSchema(ctx, n.Addr, provider)
 	if err != nil {
 		diags = diags.Append(err)
 		return nil, diags.NonFatalErr()
@@ -111,12 +124,76 @@ type EvalInputProvider struct {
 }
 
 func (n *EvalInputProvider) Eval(ctx EvalContext) (interface{}, error) {
-	// This is currently disabled. It used to interact with a provider method
-	// called Input, allowing the provider to capture input interactively
-	// itself, but once re-implemented we'll have this instead use the
-	// provider's configuration schema to automatically infer what we need
-	// to prompt for.
 	var diags tfdiags.Diagnostics
-	diags = diags.Append(tfdiags.SimpleWarning(fmt.Sprintf("%s: provider input is temporarily disabled", n.Addr)))
+
+	provider := *n.Provider
+	config := *n.Config
+
+	schema, err := providerSchema(ctx, n.Addr, provider)
+	if err != nil {
+		diags = diags.Append(err)
+		return nil, diags.NonFatalErr()
+	}
+	configSchema := schema.Provider
+
+	// We only use this evaluation to see which required attributes are
+	// already satisfied; any errors here will be reported properly,
+	// with full context, during the later configure walk.
+	configBody := buildProviderConfig(ctx, n.Addr, config.Config)
+	configVal, _, _ := ctx.EvaluateBlock(configBody, configSchema, nil)
+
+	// Stdin-sourced input is only used if the caller that built this
+	// context explicitly opted in (see StdinInputContext); we never infer
+	// "read JSON from stdin" just because there's no UIInput attached, as
+	// that would turn "-input=false" at an interactive terminal into an
+	// indefinite hang instead of the no-prompt behavior that flag exists
+	// to provide.
+	var interactive InputProvider = &UIInputProvider{UIInput: ctx.Input()}
+	if sc, ok := ctx.(StdinInputContext); ok {
+		if stdin := sc.StdinInput(); stdin != nil {
+			interactive = stdin
+		}
+	}
+
+	input := &ChainInputProvider{
+		Providers: []InputProvider{
+			&EnvInputProvider{Addr: n.Addr},
+			interactive,
+		},
+	}
+
+	values := make(map[string]cty.Value)
+	for name, attrS := range configSchema.Attributes {
+		if !attrS.Required {
+			continue
+		}
+		if configVal.Type().HasAttribute(name) {
+			if v := configVal.GetAttr(name); v.IsKnown() && !v.IsNull() {
+				// Already satisfied by configuration (or a previous
+				// input walk), nothing to prompt for.
+				continue
+			}
+		}
+
+		raw, ok := input.Input(name, attrS)
+		if !ok {
+			continue
+		}
+
+		v, err := convert.Convert(cty.StringVal(raw), attrS.Type)
+		if err != nil {
+			diags = diags.Append(tfdiags.SimpleWarning(fmt.Sprintf(
+				"%s: invalid value for %q: %s", n.Addr, name, err,
+			)))
+			continue
+		}
+
+		values[name] = v
+	}
+
+	if len(values) > 0 {
+		ctx.SetProviderInput(n.Addr, values)
+	}
+
 	return nil, diags.ErrWithWarnings()
 }
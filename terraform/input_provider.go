@@ -0,0 +1,153 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/config/configschema"
+)
+
+// InputProvider is implemented by something that can supply a value for a
+// single missing provider configuration argument, identified by name and
+// described by its schema. It returns false if it has no answer, so that
+// the caller can fall back to another source.
+//
+// Terraform ships a handful of built-in implementations -- UIInputProvider,
+// EnvInputProvider, and StdinInputProvider -- composed together with
+// ChainInputProvider so that EvalInputProvider doesn't need to know which
+// one actually answers.
+type InputProvider interface {
+	Input(name string, schema *configschema.Attribute) (string, bool)
+}
+
+// ChainInputProvider tries each of Providers in turn, returning the first
+// value any of them can supply.
+type ChainInputProvider struct {
+	Providers []InputProvider
+}
+
+func (p *ChainInputProvider) Input(name string, schema *configschema.Attribute) (string, bool) {
+	for _, sub := range p.Providers {
+		if sub == nil {
+			continue
+		}
+		if v, ok := sub.Input(name, schema); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// UIInputProvider adapts a UIInput into an InputProvider, prompting the
+// operator interactively through whatever UI Terraform is attached to
+// (typically a terminal). Attributes marked Sensitive in the schema are
+// requested without echo.
+type UIInputProvider struct {
+	UIInput UIInput
+}
+
+func (p *UIInputProvider) Input(name string, schema *configschema.Attribute) (string, bool) {
+	if p.UIInput == nil {
+		return "", false
+	}
+
+	query := name
+	if schema.Description != "" {
+		query = fmt.Sprintf("%s (%s)", name, schema.Description)
+	}
+
+	v, err := p.UIInput.Input(context.Background(), &InputOpts{
+		Id:          name,
+		Query:       query,
+		Description: schema.Description,
+		Secret:      schema.Sensitive,
+	})
+	if err != nil {
+		log.Printf("[WARN] Failed to read input for %q: %s", name, err)
+		return "", false
+	}
+
+	return v, true
+}
+
+// EnvInputProvider satisfies missing provider configuration arguments from
+// environment variables, for non-interactive use. Because a configuration
+// can have many instances of the same provider type, the variable name
+// includes the provider configuration address: a "region" attribute on a
+// provider configured as aws.west is read from
+// TF_PROVIDER_AWS_WEST_REGION.
+type EnvInputProvider struct {
+	Addr addrs.ProviderConfig
+}
+
+func (p *EnvInputProvider) Input(name string, schema *configschema.Attribute) (string, bool) {
+	key := envVarName(p.Addr, name)
+	return os.LookupEnv(key)
+}
+
+func envVarName(addr addrs.ProviderConfig, name string) string {
+	replacer := strings.NewReplacer(".", "_", "-", "_")
+	providerName := addr.Type
+	if addr.Alias != "" {
+		providerName += "." + addr.Alias
+	}
+	return "TF_PROVIDER_" + strings.ToUpper(replacer.Replace(providerName)) + "_" + strings.ToUpper(name)
+}
+
+// StdinInputProvider answers from a single JSON object of attribute name to
+// string value, decoded once from Reader (typically os.Stdin) the first
+// time a value is requested. This is intended for fully non-interactive use,
+// such as a scripted CI pipeline that already knows the answers up front
+// and has no TTY to prompt on.
+//
+// A single StdinInputProvider instance must be shared by every caller that
+// might read from it during a walk, never one constructed per call: Reader
+// is consumed exactly once (guarded by the embedded sync.Once below), so a
+// second, independently-constructed instance over the same underlying
+// stdin would resume reading wherever the first instance's json.Decoder
+// buffered ahead to, not at the start of a JSON object.
+type StdinInputProvider struct {
+	Reader io.Reader
+
+	once   sync.Once
+	values map[string]string
+}
+
+func (p *StdinInputProvider) Input(name string, schema *configschema.Attribute) (string, bool) {
+	p.once.Do(func() {
+		p.values = make(map[string]string)
+		if p.Reader == nil {
+			return
+		}
+		dec := json.NewDecoder(p.Reader)
+		if err := dec.Decode(&p.values); err != nil {
+			log.Printf("[WARN] Failed to decode provider input from stdin: %s", err)
+		}
+	})
+
+	v, ok := p.values[name]
+	return v, ok
+}
+
+// StdinInputContext is implemented by EvalContext implementations (see
+// BuiltinEvalContext) that support answering provider input from a single
+// JSON object on stdin. It's deliberately a separate, optional interface
+// rather than something EvalInputProvider infers from Input() returning
+// nil: a run with no UIInput attached (e.g. -input=false) is not by
+// itself permission to block on a read of stdin, since stdin may well be
+// an interactive terminal the operator never expected to be prompted on.
+// Reading JSON from stdin is only attempted when the caller that
+// constructed the context has explicitly opted in by setting
+// StdinInputValue.
+type StdinInputContext interface {
+	// StdinInput returns the shared InputProvider to consult for
+	// stdin-sourced provider input, or nil if this run hasn't opted in.
+	StdinInput() InputProvider
+}
@@ -2,7 +2,6 @@ package terraform
 
 import (
 	"log"
-	"strings"
 
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hcl/hclsyntax"
@@ -49,8 +48,17 @@ type NodeAbstractResource struct {
 	// interfaces if you're running those transforms, but also be explicitly
 	// set if you already have that information.
 
-	Schema *configschema.Block // Schema for processing the configuration body
-	Config *configs.Resource   // Config is the resource in the config
+	// Schema is the provider's schema for this resource type, used for
+	// processing the configuration body (e.g. by References, below). It's
+	// populated by whatever AttachSchemaTransformer is in use, which
+	// fetches it directly from the provider rather than consulting
+	// BuiltinEvalContext's shared ProviderSchema cache -- wiring this and
+	// References up to go through that cache instead, so that every node
+	// sharing a provider reuses the one fetched copy instead of each
+	// asking the provider again, is tracked as follow-up work rather than
+	// included in this change.
+	Schema *configschema.Block
+	Config *configs.Resource // Config is the resource in the config
 
 	Targets []ResourceAddress // Set from GraphNodeTargetable
 
@@ -59,14 +67,15 @@ type NodeAbstractResource struct {
 }
 
 var (
-	_ GraphNodeSubPath              = (*NodeAbstractResource)(nil)
-	_ GraphNodeReferenceable        = (*NodeAbstractResource)(nil)
-	_ GraphNodeReferencer           = (*NodeAbstractResource)(nil)
-	_ GraphNodeProviderConsumer     = (*NodeAbstractResource)(nil)
-	_ GraphNodeProvisionerConsumer  = (*NodeAbstractResource)(nil)
-	_ GraphNodeResource             = (*NodeAbstractResource)(nil)
-	_ GraphNodeAttachResourceConfig = (*NodeAbstractResource)(nil)
-	_ dag.GraphNodeDotter           = (*NodeAbstractResource)(nil)
+	_ GraphNodeSubPath                = (*NodeAbstractResource)(nil)
+	_ GraphNodeReferenceable          = (*NodeAbstractResource)(nil)
+	_ GraphNodeReferencer             = (*NodeAbstractResource)(nil)
+	_ GraphNodeProviderConsumer       = (*NodeAbstractResource)(nil)
+	_ GraphNodeProvisionerConsumer    = (*NodeAbstractResource)(nil)
+	_ GraphNodeResource               = (*NodeAbstractResource)(nil)
+	_ GraphNodeAttachResourceConfig   = (*NodeAbstractResource)(nil)
+	_ GraphNodeAttachResourceProvider = (*NodeAbstractResource)(nil)
+	_ dag.GraphNodeDotter             = (*NodeAbstractResource)(nil)
 )
 
 // NewNodeAbstractResource creates an abstract resource graph node for
@@ -231,61 +240,93 @@ func (n *NodeAbstractResourceInstance) References() []*addrs.Reference {
 	return nil
 }
 
-// StateReferences returns the dependencies to put into the state for
-// this resource.
-func (n *NodeAbstractResource) StateReferences() []string {
-	self := n.ReferenceableName()
-
-	// Determine what our "prefix" is for checking for references to
-	// ourself.
-	addrCopy := n.Addr.Copy()
-	addrCopy.Index = -1
-	selfPrefix := addrCopy.String() + "."
-
-	depsRaw := n.References()
-	deps := make([]string, 0, len(depsRaw))
-	for _, d := range depsRaw {
-		// Ignore any variable dependencies
-		if strings.HasPrefix(d, "var.") {
-			continue
+// StateDependencies returns the set of addresses that this resource depends
+// on, for recording in the new (addrs-based) state format.
+//
+// It's derived from References(), which returns every addrs.Reference found
+// in configuration, by filtering down to what's relevant for the state's
+// dependency tracking: input variables aren't recorded at all (they aren't
+// resources, so there's nothing to order against), a reference to a
+// specific instance of another resource collapses to its containing
+// addrs.Resource (since state orders by resource, not instance), a
+// reference to this resource itself or to any of its instances is dropped
+// except for its own zeroth instance, which is kept exact, and a reference
+// into a child module call is truncated to the call itself.
+func (n *NodeAbstractResource) StateDependencies() []addrs.Referenceable {
+	self := n.Addr.Resource
+
+	var result []addrs.Referenceable
+	seen := make(map[string]struct{})
+	add := func(r addrs.Referenceable) {
+		key := r.String()
+		if _, exists := seen[key]; exists {
+			return
 		}
+		seen[key] = struct{}{}
+		result = append(result, r)
+	}
 
-		// If this has a backup ref, ignore those for now. The old state
-		// file never contained those and I'd rather store the rich types we
-		// add in the future.
-		if idx := strings.IndexRune(d, '/'); idx != -1 {
-			d = d[:idx]
-		}
+	for _, ref := range n.References() {
+		switch s := ref.Subject.(type) {
+		case addrs.InputVariable:
+			// Variables aren't resources, so they have no place in the
+			// state's dependency list.
+			continue
 
-		// If we're referencing ourself, then ignore it
-		found := false
-		for _, s := range self {
-			if d == s {
-				found = true
+		case addrs.ResourceInstance:
+			if s.Resource == self {
+				// A reference to a specific instance of ourself (e.g.
+				// "foo.bar[0]" referenced from within "foo.bar") is kept
+				// exact, the same way the legacy string-based format used
+				// to special-case a ".0" suffix.
+				if s.Key == addrs.IntKey(0) {
+					add(s)
+				}
+				continue
 			}
-		}
-		if found {
-			continue
-		}
+			add(s.Resource)
 
-		// If this is a reference to ourself and a specific index, we keep
-		// it. For example, if this resource is "foo.bar" and the reference
-		// is "foo.bar.0" then we keep it exact. Otherwise, we strip it.
-		if strings.HasSuffix(d, ".0") && !strings.HasPrefix(d, selfPrefix) {
-			d = d[:len(d)-2]
-		}
+		case addrs.Resource:
+			if s == self {
+				continue
+			}
+			add(s)
 
-		// This is sad. The dependencies are currently in the format of
-		// "module.foo.bar" (the full field). This strips the field off.
-		if strings.HasPrefix(d, "module.") {
-			parts := strings.SplitN(d, ".", 3)
-			d = strings.Join(parts[0:2], ".")
-		}
+		case addrs.ModuleCallInstance:
+			add(s.Call)
+
+		case addrs.ModuleCallOutput:
+			// "module.foo.some_output" in a single-instance module: the
+			// output reference is truncated to the call itself, same as
+			// the legacy string-based format did unconditionally for any
+			// "module."-prefixed dependency string.
+			add(s.Call)
 
-		deps = append(deps, d)
+		case addrs.ModuleCallInstanceOutput:
+			// "module.foo[0].some_output": truncate through the instance
+			// down to the call, for the same reason as above.
+			add(s.Call.Call)
+
+		default:
+			add(s)
+		}
 	}
 
-	return deps
+	return result
+}
+
+// StateReferences returns the dependencies to put into the state for this
+// resource, as legacy address strings. This exists only because the state
+// file itself still stores dependencies this way; new code should prefer
+// StateDependencies, which returns the structured addrs.Referenceable
+// values this is derived from.
+func (n *NodeAbstractResource) StateReferences() []string {
+	deps := n.StateDependencies()
+	result := make([]string, len(deps))
+	for i, d := range deps {
+		result[i] = d.String()
+	}
+	return result
 }
 
 func (n *NodeAbstractResource) SetProvider(p addrs.AbsProviderConfig) {
@@ -294,6 +335,18 @@ func (n *NodeAbstractResource) SetProvider(p addrs.AbsProviderConfig) {
 
 // GraphNodeProviderConsumer
 func (n *NodeAbstractResource) ProvidedBy() (addrs.AbsProviderConfig, bool) {
+	// If AttachResourceProvider already resolved and attached a specific
+	// provider configuration instance -- from the resource's "provider"
+	// meta-argument, validated against the module's declared provider
+	// configurations by AttachResourceConfigTransformer -- prefer that
+	// over recomputing the same thing from Config below, so that the
+	// later provider-routing transformer's call to SetProvider doesn't
+	// just silently rediscover the same answer and make this attachment
+	// a no-op.
+	if n.ResolvedProvider.ProviderConfig.Type != "" {
+		return n.ResolvedProvider, false
+	}
+
 	// If we have a config we prefer that above all else
 	if n.Config != nil {
 		relAddr := n.Config.ProviderConfigAddr()
@@ -306,6 +359,13 @@ func (n *NodeAbstractResource) ProvidedBy() (addrs.AbsProviderConfig, bool) {
 
 // GraphNodeProviderConsumer
 func (n *NodeAbstractResourceInstance) ProvidedBy() (addrs.AbsProviderConfig, bool) {
+	// See the equivalent check in NodeAbstractResource.ProvidedBy: prefer
+	// an already-resolved and attached provider configuration instance
+	// over recomputing it below.
+	if n.ResolvedProvider.ProviderConfig.Type != "" {
+		return n.ResolvedProvider, false
+	}
+
 	// If we have a config we prefer that above all else
 	if n.Config != nil {
 		relAddr := n.Config.ProviderConfigAddr()
@@ -384,6 +444,11 @@ func (n *NodeAbstractResource) AttachResourceConfig(c *configs.Resource) {
 	n.Config = c
 }
 
+// GraphNodeAttachResourceProvider
+func (n *NodeAbstractResource) AttachResourceProvider(p addrs.AbsProviderConfig) {
+	n.ResolvedProvider = p
+}
+
 // GraphNodeDotter impl.
 func (n *NodeAbstractResource) DotNode(name string, opts *dag.DotOpts) *dag.DotNode {
 	return &dag.DotNode{
@@ -0,0 +1,107 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+)
+
+// TestNodeAbstractResource_StateDependencies exercises StateDependencies
+// (via StateReferences, which is what actually gets written to state)
+// against representative shapes that References() can return, to guard
+// the addrs.Referenceable type switch against regressions like silently
+// falling through to the "default: add(s)" case for a subject kind that
+// actually needs truncating -- which is exactly what happened with module
+// call outputs (module.foo.some_output) before this test was added.
+func TestNodeAbstractResource_StateDependencies(t *testing.T) {
+	self := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "test_resource",
+		Name: "self",
+	}
+
+	tests := map[string]struct {
+		dependsOn []string
+		want      []string
+	}{
+		"plain resource reference": {
+			dependsOn: []string{"aws_instance.foo"},
+			want:      []string{"aws_instance.foo"},
+		},
+		"resource instance collapses to its resource": {
+			dependsOn: []string{"aws_instance.foo[1]"},
+			want:      []string{"aws_instance.foo"},
+		},
+		"module call instance truncates to the call": {
+			dependsOn: []string{"module.foo[0]"},
+			want:      []string{"module.foo"},
+		},
+		"module call output truncates to the call": {
+			dependsOn: []string{"module.foo.some_output"},
+			want:      []string{"module.foo"},
+		},
+		"module call instance output truncates to the call": {
+			dependsOn: []string{"module.foo[0].some_output"},
+			want:      []string{"module.foo"},
+		},
+		"input variable is dropped entirely": {
+			dependsOn: []string{"var.x"},
+			want:      []string{},
+		},
+		"reference to our own zeroth instance is kept exact": {
+			dependsOn: []string{"test_resource.self[0]"},
+			want:      []string{"test_resource.self[0]"},
+		},
+		"reference to our own whole resource is dropped": {
+			dependsOn: []string{"test_resource.self"},
+			want:      []string{},
+		},
+		"reference to a non-zero instance of ourself is dropped": {
+			dependsOn: []string{"test_resource.self[1]"},
+			want:      []string{},
+		},
+		"duplicate references across instances are deduped": {
+			dependsOn: []string{"aws_instance.foo[0]", "aws_instance.foo[1]"},
+			want:      []string{"aws_instance.foo"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			n := &NodeAbstractResource{
+				Addr: addrs.AbsResource{
+					Module:   addrs.RootModuleInstance,
+					Resource: self,
+				},
+				Config: &configs.Resource{
+					DependsOn: mustParseTraversals(t, test.dependsOn),
+				},
+			}
+
+			got := n.StateReferences()
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func mustParseTraversals(t *testing.T, exprs []string) []hcl.Traversal {
+	t.Helper()
+
+	var result []hcl.Traversal
+	for _, expr := range exprs {
+		traversal, diags := hclsyntax.ParseTraversalAbs([]byte(expr), "", hcl.Pos{})
+		if diags.HasErrors() {
+			t.Fatalf("invalid traversal %q: %s", expr, diags)
+		}
+		result = append(result, traversal)
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ResourceProviderAllSchemas is an optional interface that a ResourceProvider
+// may implement to return its entire schema -- every resource type and data
+// source it supports -- in a single call. Providers that implement this
+// avoid the two-phase Resources()/DataSources()/GetSchema dance that
+// getFullProviderSchema otherwise has to do.
+type ResourceProviderAllSchemas interface {
+	ResourceProvider
+
+	// AllSchemas returns the schema for every resource type and data
+	// source the provider supports.
+	AllSchemas() (*ProviderSchema, error)
+}
+
+// getFullProviderSchema retrieves the complete schema for p: every resource
+// type and data source it implements. If p implements
+// ResourceProviderAllSchemas this is a single call; otherwise it falls back
+// to enumerating Resources()/DataSources() and asking GetSchema for all of
+// them by name.
+func getFullProviderSchema(p ResourceProvider) (*ProviderSchema, error) {
+	if ap, ok := p.(ResourceProviderAllSchemas); ok {
+		return ap.AllSchemas()
+	}
+
+	resourceTypes := p.Resources()
+	dataSources := p.DataSources()
+	resourceTypeNames := make([]string, len(resourceTypes))
+	for i, t := range resourceTypes {
+		resourceTypeNames[i] = t.Name
+	}
+	dataSourceNames := make([]string, len(dataSources))
+	for i, t := range dataSources {
+		dataSourceNames[i] = t.Name
+	}
+	return p.GetSchema(&ProviderSchemaRequest{
+		DataSources:   dataSourceNames,
+		ResourceTypes: resourceTypeNames,
+	})
+}
+
+// schemaCacheFormatVersion guards against a cached file written by an
+// incompatible version of this cache (e.g. a ProviderSchema field was
+// added or renamed). Bump it whenever the on-disk JSON shape changes; any
+// cache entry tagged with an older or newer value is treated as a miss.
+//
+// This is a stand-in for real cache invalidation: ResourceProvider doesn't
+// yet expose the plugin's version or a content hash, so schemaCacheKey
+// below can only key on the provider type name. Until that's available,
+// upgrading a provider in place will silently keep reusing its old cached
+// schema -- this constant does not protect against that case, only
+// against reading a cache file in a format this code no longer
+// understands.
+const schemaCacheFormatVersion = 1
+
+// schemaCacheEntry is the on-disk representation of a cached schema,
+// wrapping the schema itself with schemaCacheFormatVersion so a stale or
+// foreign-format file can be detected and ignored rather than trusted.
+type schemaCacheEntry struct {
+	FormatVersion int             `json:"format_version"`
+	Schema        *ProviderSchema `json:"schema"`
+}
+
+// schemaCacheKey identifies a cached provider schema on disk.
+//
+// TODO: key on the plugin's version or a content hash of the binary in
+// addition to typeName once ResourceProvider exposes one, so that
+// upgrading a provider invalidates any schema cached under the old
+// version rather than silently reusing it.
+func schemaCacheKey(typeName string) string {
+	return typeName
+}
+
+// schemaCachePath returns the on-disk path where the schema for cacheKey
+// would be stored, or "" if SchemaCacheDir is not set and so schema caching
+// is disabled.
+func (ctx *BuiltinEvalContext) schemaCachePath(cacheKey string) string {
+	if ctx.SchemaCacheDir == "" {
+		return ""
+	}
+	return filepath.Join(ctx.SchemaCacheDir, cacheKey+".json")
+}
+
+// readSchemaCache returns the previously-cached schema for cacheKey, or nil
+// if there is no cache configured, no cached entry, or the cached entry
+// can't be parsed.
+func (ctx *BuiltinEvalContext) readSchemaCache(cacheKey string) *ProviderSchema {
+	path := ctx.schemaCachePath(cacheKey)
+	if path == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var entry schemaCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("[WARN] ignoring invalid cached schema at %s: %s", path, err)
+		return nil
+	}
+
+	if entry.FormatVersion != schemaCacheFormatVersion {
+		log.Printf("[DEBUG] ignoring cached schema at %s written by cache format %d, want %d", path, entry.FormatVersion, schemaCacheFormatVersion)
+		return nil
+	}
+
+	return entry.Schema
+}
+
+// writeSchemaCache persists schema under cacheKey for reuse by a later run.
+// Failures are logged and otherwise ignored, since the cache is purely an
+// optimization.
+//
+// Two aliased provider instances of the same type can legitimately finish
+// InitProvider concurrently (see providerInit in eval_context_builtin.go)
+// and both end up writing the same cache path. To avoid one of them
+// observing a half-written, corrupted file, this writes to a temp file in
+// the same directory and atomically renames it into place, rather than
+// truncating the destination in place.
+func (ctx *BuiltinEvalContext) writeSchemaCache(cacheKey string, schema *ProviderSchema) {
+	path := ctx.schemaCachePath(cacheKey)
+	if path == "" {
+		return
+	}
+
+	data, err := json.Marshal(schemaCacheEntry{
+		FormatVersion: schemaCacheFormatVersion,
+		Schema:        schema,
+	})
+	if err != nil {
+		log.Printf("[WARN] failed to marshal schema for %s: %s", cacheKey, err)
+		return
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[WARN] failed to create schema cache directory %s: %s", dir, err)
+		return
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		log.Printf("[WARN] failed to create temp file for schema cache %s: %s", path, err)
+		return
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename below succeeds
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		log.Printf("[WARN] failed to write schema cache %s: %s", path, err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("[WARN] failed to write schema cache %s: %s", path, err)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("[WARN] failed to install schema cache %s: %s", path, err)
+	}
+}
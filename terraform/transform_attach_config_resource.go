@@ -1,8 +1,10 @@
 package terraform
 
 import (
+	"fmt"
 	"log"
 
+	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/configs"
 )
 
@@ -15,6 +17,19 @@ type GraphNodeAttachResourceConfig interface {
 	AttachResourceConfig(*configs.Resource)
 }
 
+// GraphNodeAttachResourceProvider is an optional interface implemented by
+// GraphNodeAttachResourceConfig nodes that also want the concrete provider
+// configuration instance resolved and attached, for resources whose
+// configuration names a specific (possibly aliased) provider via the
+// "provider" meta-argument.
+type GraphNodeAttachResourceProvider interface {
+	GraphNodeAttachResourceConfig
+
+	// AttachResourceProvider sets the resolved provider configuration
+	// address that this resource should use.
+	AttachResourceProvider(addrs.AbsProviderConfig)
+}
+
 // AttachResourceConfigTransformer goes through the graph and attaches
 // resource configuration structures to nodes that implement
 // GraphNodeAttachManagedResourceConfig or GraphNodeAttachDataResourceConfig.
@@ -56,6 +71,9 @@ func (t *AttachResourceConfigTransformer) Transform(g *Graph) error {
 
 			log.Printf("[TRACE] AttachResourceConfigTransformer: Attaching to %s: %#v", addr.String(), r)
 			arn.AttachResourceConfig(r)
+			if err := attachResourceProvider(arn, r, addr, config); err != nil {
+				return err
+			}
 		}
 		for _, r := range config.Module.DataResources {
 			rAddr := r.Addr()
@@ -67,8 +85,41 @@ func (t *AttachResourceConfigTransformer) Transform(g *Graph) error {
 
 			log.Printf("[TRACE] AttachResourceConfigTransformer: Attaching to %s: %#v", addr.String(), r)
 			arn.AttachResourceConfig(r)
+			if err := attachResourceProvider(arn, r, addr, config); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+// attachResourceProvider resolves the provider configuration instance that
+// r should use, if arn implements GraphNodeAttachResourceProvider and r
+// names one explicitly via the "provider" meta-argument, and attaches it.
+//
+// It's valid for a resource to not reference a provider configuration at
+// all, in which case the default provider configuration for its type is
+// used and there is nothing for us to attach here; that's handled instead
+// by each node's GraphNodeProviderConsumer implementation.
+func attachResourceProvider(arn GraphNodeAttachResourceConfig, r *configs.Resource, addr addrs.AbsResource, config *configs.Config) error {
+	apn, ok := arn.(GraphNodeAttachResourceProvider)
+	if !ok || r.ProviderConfigRef == nil {
+		return nil
+	}
+
+	key := r.ProviderConfigRef.Name
+	if r.ProviderConfigRef.Alias != "" {
+		key = key + "." + r.ProviderConfigRef.Alias
+	}
+	if _, declared := config.Module.ProviderConfigs[key]; !declared {
+		return fmt.Errorf(
+			"%s: resource references provider configuration %q, which is not declared in this module",
+			addr, key,
+		)
+	}
+
+	log.Printf("[TRACE] AttachResourceConfigTransformer: Attaching provider %s to %s", key, addr.String())
+	apn.AttachResourceProvider(r.ProviderConfigAddr().Absolute(addr.Module))
+	return nil
+}
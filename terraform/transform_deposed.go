@@ -2,6 +2,10 @@ package terraform
 
 import (
 	"fmt"
+	"log"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 
 	"github.com/hashicorp/terraform/addrs"
 )
@@ -57,6 +61,7 @@ func (t *DeposedTransformer) Transform(g *Graph) error {
 				Addr:             addr,
 				Index:            i,
 				RecordedProvider: providerAddr,
+				Dependencies:     rs.Dependencies,
 			})
 		}
 	}
@@ -70,11 +75,17 @@ type graphNodeDeposedResource struct {
 	Index            int // Index into the "deposed" list in state
 	RecordedProvider addrs.AbsProviderConfig
 	ResolvedProvider addrs.AbsProviderConfig
+
+	// Dependencies are the legacy-format dependency strings recorded
+	// against the resource in state, used to populate References below.
+	Dependencies []string
 }
 
 var (
 	_ GraphNodeProviderConsumer = (*graphNodeDeposedResource)(nil)
 	_ GraphNodeEvalable         = (*graphNodeDeposedResource)(nil)
+	_ GraphNodeReferenceable    = (*graphNodeDeposedResource)(nil)
+	_ GraphNodeReferencer       = (*graphNodeDeposedResource)(nil)
 )
 
 func (n *graphNodeDeposedResource) Name() string {
@@ -91,6 +102,54 @@ func (n *graphNodeDeposedResource) SetProvider(addr addrs.AbsProviderConfig) {
 	n.RecordedProvider = addr
 }
 
+// GraphNodeReferenceable
+func (n *graphNodeDeposedResource) ReferenceableAddrs() []addrs.Referenceable {
+	return []addrs.Referenceable{
+		n.Addr.Resource,
+		n.Addr.ContainingResource().Resource,
+	}
+}
+
+// GraphNodeReferencer
+//
+// A deposed instance only ever has state (never config), so -- just like
+// NodeAbstractResourceInstance.References falls back to state when it has
+// no config -- we derive our references entirely from the legacy
+// dependency strings recorded in state at the time the resource was
+// deposed.
+func (n *graphNodeDeposedResource) References() []*addrs.Reference {
+	var result []*addrs.Reference
+	for _, legacyDep := range n.Dependencies {
+		traversal, diags := hclsyntax.ParseTraversalAbs([]byte(legacyDep), "", hcl.Pos{})
+		if diags.HasErrors() {
+			log.Printf("[ERROR] Can't parse %q from dependencies in state as a reference: invalid syntax", legacyDep)
+			continue
+		}
+		ref, err := addrs.ParseRef(traversal)
+		if err != nil {
+			log.Printf("[ERROR] Can't parse %q from dependencies in state as a reference: invalid syntax", legacyDep)
+			continue
+		}
+
+		result = append(result, ref)
+	}
+	return result
+}
+
+// SCOPE CUT, flagged for the requester rather than silently dropped:
+// graphNodeDeposedResource only makes deposed instances participate in the
+// reference graph (ReferenceableAddrs/References above). It does not
+// implement GraphNodeAddressable/GraphNodeTargetable, so a deposed
+// instance still cannot be selected with "-target" at all, let alone with
+// a deposed-aware selector like "aws_instance.foo[0] (deposed #0)" as the
+// originating request asked for. Delivering that needs a deposed-key-aware
+// address type threaded through addrs.ResourceAddress, its parser, and the
+// target-matching transformer -- none of which live in this file, and
+// none of which were touched by this change. If the operator-facing
+// targeting capability is required, this request should be reopened
+// against those components rather than considered satisfied by the
+// reference-graph work alone.
+
 // GraphNodeEvalable impl.
 func (n *graphNodeDeposedResource) EvalTree() EvalNode {
 	var provider ResourceProvider